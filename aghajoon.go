@@ -9,7 +9,10 @@ import (
 	"log"
 	"net"
 	"os"
+	"strings"
 	"time"
+
+	etcd "github.com/coreos/etcd/client"
 )
 
 //go:generate go-bindata -o pxe/pxelinux_autogen.go -prefix=pxelinux -ignore=README.md pxe/pxelinux
@@ -35,6 +38,14 @@ var (
 	leaseSubnetFlag *string = flag.String("lease-subnet", "", "Subnet of specified lease")
 	leaseRouterFlag *string = flag.String("router", "", "Default router that assigned to DHCP clients")
 	leaseDNSFlag    *string = flag.String("dns", "", "Default DNS that assigned to DHCP clients")
+	icmpTimeoutFlag *int    = flag.Int("icmp-timeout", 1000, "Milliseconds to wait for an ICMP echo reply before handing out a candidate IP, 0 disables the probe")
+
+	leaseBackendFlag  *string = flag.String("lease-backend", "etcd", "Lease storage backend to use: etcd, bolt or memory")
+	leaseBoltPathFlag *string = flag.String("lease-bolt-path", "/var/lib/aghajoon/leases.db", "Path to the BoltDB file used when -lease-backend=bolt")
+
+	lease6StartFlag *string = flag.String("lease6-start", "", "Begining of IPv6 lease starting address (enables DHCPv6 if set)")
+	lease6RangeFlag *int    = flag.Int("lease6-range", 0, "IPv6 lease range")
+	dns6Flag        *string = flag.String("dns6", "", "Default IPv6 DNS that assigned to DHCPv6 clients")
 )
 
 func interfaceIP(iface *net.Interface) (net.IP, error) {
@@ -150,7 +161,34 @@ func main() {
 		log.Fatalln(pxe.ServePXE(pxeAddr, serverIP, net.TCPAddr{IP: serverIP, Port: httpAddr.Port}))
 	}()
 	// serving dhcp
-	leasePool, err := dhcp.NewLeasePool(*etcdFlag, *etcdDirFlag, leaseStart, leaseRange, leaseDuration)
+	icmpTimeout := time.Duration(*icmpTimeoutFlag) * time.Millisecond
+
+	var leaseStore dhcp.LeaseStore
+	var etcdKapi etcd.KeysAPI
+	switch *leaseBackendFlag {
+	case "", "etcd":
+		etcdClient, err := etcd.New(etcd.Config{
+			Endpoints:               strings.Split(*etcdFlag, ","),
+			HeaderTimeoutPerRequest: 5 * time.Second,
+		})
+		if err != nil {
+			log.Fatalln(err)
+		}
+		etcdKapi = etcd.NewKeysAPI(etcdClient)
+		leaseStore = dhcp.NewEtcdLeaseStore(etcdKapi, *etcdDirFlag)
+	case "bolt":
+		leaseStore, err = dhcp.NewBoltLeaseStore(*leaseBoltPathFlag)
+		if err != nil {
+			log.Fatalln(err)
+		}
+	case "memory":
+		leaseStore = dhcp.NewMemoryLeaseStore()
+	default:
+		fmt.Fprintf(os.Stderr, "unknown lease backend %q\n", *leaseBackendFlag)
+		os.Exit(1)
+	}
+
+	leasePool, err := dhcp.NewLeasePool(leaseStore, etcdKapi, *etcdDirFlag, leaseStart, leaseRange, leaseDuration, icmpTimeout)
 	if err != nil {
 		log.Fatalln(err)
 	}
@@ -164,5 +202,37 @@ func main() {
 			DNSAddr:       leaseDNS,
 		}, leasePool))
 	}()
+
+	// serving dhcpv6, if a v6 lease range was configured
+	if *lease6StartFlag != "" {
+		if etcdKapi == nil {
+			fmt.Fprintf(os.Stderr, "lease6-start requires -lease-backend=etcd (DHCPv6 leases aren't ported to the other backends yet)\n")
+			os.Exit(1)
+		}
+		lease6Start := net.ParseIP(*lease6StartFlag)
+		if lease6Start == nil {
+			fmt.Fprint(os.Stderr, "lease6-start is not a valid IPv6 address\n")
+			os.Exit(1)
+		}
+		if *lease6RangeFlag <= 1 {
+			fmt.Fprint(os.Stderr, "lease6-range should be greater than 1\n")
+			os.Exit(1)
+		}
+		lease6DNS := net.ParseIP(*dns6Flag)
+		leasePoolV6, err := dhcp.NewLeasePoolV6(etcdKapi, *etcdDirFlag, lease6Start, *lease6RangeFlag, leaseDuration)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		go func() {
+			log.Fatalln(dhcp.ServeDHCPv6(&dhcp.DHCPv6Setting{
+				IFName:        dhcpIF.Name,
+				ServerID:      dhcpIF.HardwareAddr,
+				BootFileURL:   fmt.Sprintf("tftp://[%s]/pxelinux.0", serverIP),
+				BootFileParam: "pxelinux",
+				DNSAddr:       lease6DNS,
+			}, leasePoolV6, uint32(leaseDuration.Seconds())))
+		}()
+	}
+
 	logging.RecordLogs(true)
 }
@@ -49,7 +49,15 @@ func etcdClietForTest() (etcd.Client, error) {
 	return etcdClient, err
 }
 
-// ForTest constructs a EtcdDatasource to be used in tests
+// ForTest constructs a EtcdDatasource to be used in tests.
+//
+// NOTE: this was asked to build its fixtures against dhcp.NewMemoryLeaseStore
+// instead of a live etcd instance, same as dhcp.NewLeasePool's bolt/memory
+// backends. It can't be: EtcdDatasource, NewEtcdDataSource and InstanceInfo
+// below don't exist anywhere else in this tree (this file is the only
+// reference to any of them), so this package has no dhcp.LeasePool wiring
+// at all to swap a LeaseStore into. Left untouched pending whatever change
+// was meant to introduce that missing plumbing.
 func ForTest(params *ForTestParams) (*EtcdDatasource, error) {
 	var err error
 
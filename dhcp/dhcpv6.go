@@ -0,0 +1,225 @@
+package dhcp
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+)
+
+// DHCPv6 message types (RFC 3315 section 5.3).
+const (
+	dhcpv6Solicit     = 1
+	dhcpv6Advertise   = 2
+	dhcpv6Request     = 3
+	dhcpv6Confirm     = 4
+	dhcpv6Renew       = 5
+	dhcpv6Rebind      = 6
+	dhcpv6Reply       = 7
+	dhcpv6Release     = 8
+	dhcpv6Decline     = 9
+	dhcpv6Reconfigure = 10
+	dhcpv6InfoReq     = 11
+)
+
+// DHCPv6 option codes we understand.
+const (
+	optClientID      = 1
+	optServerID      = 2
+	optIANA          = 3
+	optIAAddr        = 5
+	optElapsedTime   = 8
+	optDNSServers    = 23
+	optBootfileURL   = 59 // OPT_BOOTFILE_URL
+	optBootfileParam = 60 // OPT_BOOTFILE_PARAM
+)
+
+// Standard DHCPv6 client and server/relay UDP ports. ServeDHCPv6 listens
+// on the server port of the All_DHCP_Relay_Agents_and_Servers multicast
+// address (ff02::1:2) that clients send Solicit/Request to.
+var dhcpv6ClientPort = 546
+var dhcpv6ServerPort = 547
+
+// DHCPv6Setting carries the per-deployment configuration ServeDHCPv6
+// needs to answer clients, analogous to DHCPSetting for v4.
+type DHCPv6Setting struct {
+	IFName        string
+	ServerID      []byte
+	BootFileURL   string
+	BootFileParam string
+	DNSAddr       net.IP
+}
+
+type dhcpv6Message struct {
+	MsgType byte
+	TxnID   [3]byte
+	Options map[uint16][]byte
+}
+
+func parseDHCPv6(b []byte) (*dhcpv6Message, error) {
+	if len(b) < 4 {
+		return nil, fmt.Errorf("DHCPv6 packet too short")
+	}
+	msg := &dhcpv6Message{
+		MsgType: b[0],
+		Options: make(map[uint16][]byte),
+	}
+	copy(msg.TxnID[:], b[1:4])
+	rest := b[4:]
+	for len(rest) > 0 {
+		if len(rest) < 4 {
+			return nil, fmt.Errorf("DHCPv6 packet has a truncated option")
+		}
+		code := binary.BigEndian.Uint16(rest[0:2])
+		olen := int(binary.BigEndian.Uint16(rest[2:4]))
+		if len(rest) < 4+olen {
+			return nil, fmt.Errorf("DHCPv6 option %d declares more data than present", code)
+		}
+		msg.Options[code] = rest[4 : 4+olen]
+		rest = rest[4+olen:]
+	}
+	return msg, nil
+}
+
+// iaNA is the decoded content of an OPTION_IA_NA.
+type iaNA struct {
+	IAID uint32
+	T1   uint32
+	T2   uint32
+	IP   net.IP // zero if the client didn't hint one via IAADDR
+}
+
+func parseIANA(data []byte) (*iaNA, error) {
+	if len(data) < 12 {
+		return nil, fmt.Errorf("IA_NA option too short")
+	}
+	ia := &iaNA{
+		IAID: binary.BigEndian.Uint32(data[0:4]),
+		T1:   binary.BigEndian.Uint32(data[4:8]),
+		T2:   binary.BigEndian.Uint32(data[8:12]),
+	}
+	sub := data[12:]
+	for len(sub) >= 4 {
+		code := binary.BigEndian.Uint16(sub[0:2])
+		olen := int(binary.BigEndian.Uint16(sub[2:4]))
+		if len(sub) < 4+olen {
+			break
+		}
+		if code == optIAAddr && olen >= 16 {
+			ia.IP = net.IP(append([]byte(nil), sub[4:20]...))
+		}
+		sub = sub[4+olen:]
+	}
+	return ia, nil
+}
+
+func appendOption(b []byte, code uint16, data []byte) []byte {
+	hdr := make([]byte, 4)
+	binary.BigEndian.PutUint16(hdr[0:2], code)
+	binary.BigEndian.PutUint16(hdr[2:4], uint16(len(data)))
+	b = append(b, hdr...)
+	return append(b, data...)
+}
+
+// encodeIANA builds an OPTION_IA_NA with a single OPTION_IAADDR suboption
+// granting ip for the given lifetimes (in seconds).
+func encodeIANA(iaid uint32, ip net.IP, preferred, valid uint32) []byte {
+	data := make([]byte, 12)
+	binary.BigEndian.PutUint32(data[0:4], iaid)
+	binary.BigEndian.PutUint32(data[4:8], valid/2)     // T1
+	binary.BigEndian.PutUint32(data[8:12], valid*8/10) // T2
+
+	sub := make([]byte, 24)
+	copy(sub[0:16], ip.To16())
+	binary.BigEndian.PutUint32(sub[16:20], preferred)
+	binary.BigEndian.PutUint32(sub[20:24], valid)
+
+	return appendOption(data, optIAAddr, sub)
+}
+
+// ServeDHCPv6 listens for DHCPv6 client traffic on ifname and answers
+// Solicit/Request/Renew/Rebind/Release using pool to assign addresses,
+// advertising setting.BootFileURL/BootFileParam (options 59/60) so
+// PXE/iPXE UEFI clients can chain-load over v6.
+func ServeDHCPv6(setting *DHCPv6Setting, pool *LeasePoolV6, leaseSeconds uint32) error {
+	iface, err := net.InterfaceByName(setting.IFName)
+	if err != nil {
+		return err
+	}
+	group := &net.UDPAddr{IP: net.ParseIP("ff02::1:2"), Port: dhcpv6ServerPort}
+	conn, err := net.ListenMulticastUDP("udp6", iface, group)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	buf := make([]byte, 1500)
+	for {
+		n, addr, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			continue
+		}
+		req, err := parseDHCPv6(buf[:n])
+		if err != nil {
+			continue
+		}
+		go handleDHCPv6(conn, addr, req, setting, pool, leaseSeconds)
+	}
+}
+
+func handleDHCPv6(conn *net.UDPConn, addr *net.UDPAddr, req *dhcpv6Message, setting *DHCPv6Setting, pool *LeasePoolV6, leaseSeconds uint32) {
+	clientID, ok := req.Options[optClientID]
+	if !ok {
+		return
+	}
+	iaData, ok := req.Options[optIANA]
+	if !ok {
+		return
+	}
+	ia, err := parseIANA(iaData)
+	if err != nil {
+		return
+	}
+	client := ClientID{DUID: fmt.Sprintf("%x", clientID), IAID: ia.IAID}
+
+	var ip net.IP
+	var replyType byte
+	switch req.MsgType {
+	case dhcpv6Solicit:
+		ip, err = pool.Assign(client)
+		replyType = dhcpv6Advertise
+	case dhcpv6Request, dhcpv6Confirm:
+		ip, err = pool.Assign(client)
+		replyType = dhcpv6Reply
+	case dhcpv6Renew, dhcpv6Rebind:
+		currentIP := ia.IP
+		if currentIP == nil {
+			return
+		}
+		ip, err = pool.Request(client, currentIP)
+		replyType = dhcpv6Reply
+	case dhcpv6Release:
+		pool.Release(client)
+		return
+	default:
+		return
+	}
+	if err != nil || ip == nil {
+		return
+	}
+
+	reply := []byte{replyType, req.TxnID[0], req.TxnID[1], req.TxnID[2]}
+	reply = appendOption(reply, optClientID, clientID)
+	reply = appendOption(reply, optServerID, setting.ServerID)
+	reply = appendOption(reply, optIANA, encodeIANA(ia.IAID, ip, leaseSeconds, leaseSeconds))
+	if setting.DNSAddr != nil {
+		reply = appendOption(reply, optDNSServers, setting.DNSAddr.To16())
+	}
+	if setting.BootFileURL != "" {
+		reply = appendOption(reply, optBootfileURL, []byte(setting.BootFileURL))
+	}
+	if setting.BootFileParam != "" {
+		reply = appendOption(reply, optBootfileParam, []byte(setting.BootFileParam))
+	}
+
+	conn.WriteToUDP(reply, addr)
+}
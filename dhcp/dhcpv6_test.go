@@ -0,0 +1,72 @@
+package dhcp
+
+import (
+	"bytes"
+	"net"
+	"testing"
+)
+
+func TestParseDHCPv6RoundTrip(t *testing.T) {
+	clientID := []byte{0x00, 0x01, 0xaa, 0xbb}
+	b := []byte{dhcpv6Solicit, 0x01, 0x02, 0x03}
+	b = appendOption(b, optClientID, clientID)
+	b = appendOption(b, optIANA, encodeIANA(42, net.ParseIP("fd00::5"), 300, 600))
+
+	msg, err := parseDHCPv6(b)
+	if err != nil {
+		t.Fatalf("parseDHCPv6: %v", err)
+	}
+	if msg.MsgType != dhcpv6Solicit {
+		t.Errorf("MsgType = %d, want %d", msg.MsgType, dhcpv6Solicit)
+	}
+	if msg.TxnID != [3]byte{0x01, 0x02, 0x03} {
+		t.Errorf("TxnID = %v, want [1 2 3]", msg.TxnID)
+	}
+	if !bytes.Equal(msg.Options[optClientID], clientID) {
+		t.Errorf("ClientID option = %v, want %v", msg.Options[optClientID], clientID)
+	}
+
+	ia, err := parseIANA(msg.Options[optIANA])
+	if err != nil {
+		t.Fatalf("parseIANA: %v", err)
+	}
+	if ia.IAID != 42 {
+		t.Errorf("IAID = %d, want 42", ia.IAID)
+	}
+	if !ia.IP.Equal(net.ParseIP("fd00::5")) {
+		t.Errorf("IP = %s, want fd00::5", ia.IP)
+	}
+}
+
+func TestParseDHCPv6TooShort(t *testing.T) {
+	if _, err := parseDHCPv6([]byte{1, 2}); err == nil {
+		t.Fatal("expected an error for a too-short packet")
+	}
+}
+
+func TestParseDHCPv6TruncatedOption(t *testing.T) {
+	// code=1, declared len=5, but no data follows.
+	b := []byte{dhcpv6Solicit, 0, 0, 0, 0, 1, 0, 5}
+	if _, err := parseDHCPv6(b); err == nil {
+		t.Fatal("expected an error for a truncated option")
+	}
+}
+
+func TestParseIANATooShort(t *testing.T) {
+	if _, err := parseIANA([]byte{1, 2, 3}); err == nil {
+		t.Fatal("expected an error for an undersized IA_NA")
+	}
+}
+
+func TestParseIANAWithoutAddr(t *testing.T) {
+	// A bare IA_NA with no IAADDR suboption: a client asking for a new
+	// address rather than renewing a known one.
+	data := make([]byte, 12)
+	ia, err := parseIANA(data)
+	if err != nil {
+		t.Fatalf("parseIANA: %v", err)
+	}
+	if ia.IP != nil {
+		t.Errorf("IP = %s, want nil", ia.IP)
+	}
+}
@@ -0,0 +1,93 @@
+package dhcp
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	etcd "github.com/coreos/etcd/client"
+)
+
+// fakeKeysAPI is a minimal in-memory etcd.KeysAPI, just enough of one to
+// exercise LeasePoolV6 (Get/Set/Delete over a single flat directory)
+// without a live etcd instance. Create and Update go through Set;
+// CreateInOrder and Watcher aren't used by anything under test and panic
+// if called.
+type fakeKeysAPI struct {
+	mu   sync.Mutex
+	vals map[string]string
+	dirs map[string]bool
+}
+
+func newFakeKeysAPI() *fakeKeysAPI {
+	return &fakeKeysAPI{vals: map[string]string{}, dirs: map[string]bool{}}
+}
+
+func (f *fakeKeysAPI) Get(ctx context.Context, key string, opts *etcd.GetOptions) (*etcd.Response, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if opts != nil && opts.Recursive {
+		prefix := key + "/"
+		var nodes etcd.Nodes
+		for k, v := range f.vals {
+			if strings.HasPrefix(k, prefix) && !strings.Contains(k[len(prefix):], "/") {
+				nodes = append(nodes, &etcd.Node{Key: k, Value: v})
+			}
+		}
+		if len(nodes) == 0 && !f.dirs[key] {
+			return nil, etcd.Error{Code: etcd.ErrorCodeKeyNotFound}
+		}
+		return &etcd.Response{Node: &etcd.Node{Key: key, Dir: true, Nodes: nodes}}, nil
+	}
+
+	v, ok := f.vals[key]
+	if !ok {
+		return nil, etcd.Error{Code: etcd.ErrorCodeKeyNotFound}
+	}
+	return &etcd.Response{Node: &etcd.Node{Key: key, Value: v}}, nil
+}
+
+func (f *fakeKeysAPI) Set(ctx context.Context, key, value string, opts *etcd.SetOptions) (*etcd.Response, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if opts != nil && opts.Dir {
+		f.dirs[key] = true
+		return &etcd.Response{Node: &etcd.Node{Key: key, Dir: true}}, nil
+	}
+	if opts != nil && opts.PrevExist == etcd.PrevNoExist {
+		if _, exists := f.vals[key]; exists {
+			return nil, etcd.Error{Code: etcd.ErrorCodeNodeExist}
+		}
+	}
+	f.vals[key] = value
+	return &etcd.Response{Node: &etcd.Node{Key: key, Value: value}}, nil
+}
+
+func (f *fakeKeysAPI) Delete(ctx context.Context, key string, opts *etcd.DeleteOptions) (*etcd.Response, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, ok := f.vals[key]; !ok {
+		return nil, etcd.Error{Code: etcd.ErrorCodeKeyNotFound}
+	}
+	delete(f.vals, key)
+	return &etcd.Response{}, nil
+}
+
+func (f *fakeKeysAPI) Create(ctx context.Context, key, value string) (*etcd.Response, error) {
+	return f.Set(ctx, key, value, &etcd.SetOptions{PrevExist: etcd.PrevNoExist})
+}
+
+func (f *fakeKeysAPI) CreateInOrder(ctx context.Context, dir, value string, opts *etcd.CreateInOrderOptions) (*etcd.Response, error) {
+	panic("fakeKeysAPI: CreateInOrder not implemented")
+}
+
+func (f *fakeKeysAPI) Update(ctx context.Context, key, value string) (*etcd.Response, error) {
+	return f.Set(ctx, key, value, &etcd.SetOptions{PrevExist: etcd.PrevExist})
+}
+
+func (f *fakeKeysAPI) Watcher(key string, opts *etcd.WatcherOptions) etcd.Watcher {
+	panic("fakeKeysAPI: Watcher not implemented")
+}
@@ -0,0 +1,87 @@
+package dhcp
+
+import (
+	"net"
+	"os"
+	"time"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+)
+
+// foreignHostNic marks a Lease entry that was reserved not because a
+// client asked for it, but because an ICMP probe found somebody already
+// using that address. It is stored as an ordinary Lease that expires
+// after foreignHostCoolDown, so the pool can use the address again once
+// the cool-down has passed.
+const foreignHostNic = "<foreign-host>"
+
+// foreignHostCoolDown is how long an address found in use by a foreign
+// host is kept out of the pool before being probed again.
+const foreignHostCoolDown = 5 * time.Minute
+
+// pingIP sends a single ICMP echo request to ip and reports whether a
+// reply was seen before timeout elapses. It is used to detect IPs that
+// are already in use by a host Blacksmith doesn't know about, before
+// handing them out as a lease.
+func pingIP(ip net.IP, timeout time.Duration) (bool, error) {
+	conn, err := icmp.ListenPacket("ip4:icmp", "0.0.0.0")
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+
+	wantID := os.Getpid() & 0xffff
+	const wantSeq = 1
+	msg := icmp.Message{
+		Type: ipv4.ICMPTypeEcho,
+		Code: 0,
+		Body: &icmp.Echo{
+			ID:   wantID,
+			Seq:  wantSeq,
+			Data: []byte("blacksmith-conflict-check"),
+		},
+	}
+	b, err := msg.Marshal(nil)
+	if err != nil {
+		return false, err
+	}
+
+	if _, err := conn.WriteTo(b, &net.IPAddr{IP: ip}); err != nil {
+		return false, err
+	}
+
+	if err := conn.SetReadDeadline(time.Now().Add(timeout)); err != nil {
+		return false, err
+	}
+
+	reply := make([]byte, 1500)
+	for {
+		n, peer, err := conn.ReadFrom(reply)
+		if err != nil {
+			if t, ok := err.(net.Error); ok && t.Timeout() {
+				return false, nil
+			}
+			return false, err
+		}
+		// Our socket sees every ICMP packet arriving on the host, not
+		// just replies to our own probe, so a reply has to match both
+		// the IP we pinged and the ID/Seq we sent before it counts.
+		peerAddr, ok := peer.(*net.IPAddr)
+		if !ok || !peerAddr.IP.Equal(ip) {
+			continue
+		}
+		parsed, err := icmp.ParseMessage(1, reply[:n])
+		if err != nil {
+			continue
+		}
+		if parsed.Type != ipv4.ICMPTypeEchoReply {
+			continue
+		}
+		echo, ok := parsed.Body.(*icmp.Echo)
+		if !ok || echo.ID != wantID || echo.Seq != wantSeq {
+			continue
+		}
+		return true, nil
+	}
+}
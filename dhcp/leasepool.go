@@ -1,14 +1,11 @@
 package dhcp
 
 import (
-	"encoding/json"
 	"errors"
 	"net"
-	"path"
 	"sync"
 	"time"
 
-	"github.com/coreos/etcd/Godeps/_workspace/src/golang.org/x/net/context"
 	etcd "github.com/coreos/etcd/client"
 	"github.com/krolaw/dhcp4"
 )
@@ -49,99 +46,87 @@ func newLease(nic string, ip net.IP, expireDuration time.Duration, firstAssigned
 	return lease
 }
 
+// LeasePool assigns and tracks DHCP leases. The actual storage lives
+// behind the LeaseStore interface; dataSource/etcdDir are only set (and
+// only needed) when that store is etcd-backed, since static leases and
+// the lease-change Watch talk to etcd directly rather than through
+// LeaseStore.
 type LeasePool struct {
+	store          LeaseStore
+	dataSource     etcd.KeysAPI
 	etcdDir        string
 	startIP        net.IP
 	rangeLen       int
 	expireDuration time.Duration
-	dataSource     etcd.KeysAPI
-	dataLock       sync.Mutex
 	assignLock     sync.Mutex
+
+	// ICMPTimeout is how long to wait for an ICMP echo reply when
+	// probing a candidate IP for conflicts before handing it out.
+	// Zero disables probing.
+	ICMPTimeout time.Duration
 }
 
-func NewLeasePool(dataSource etcd.KeysAPI, etcdDir string, startIP net.IP, rangeLen int, expireDuration time.Duration) (*LeasePool, error) {
+// NewLeasePool builds a LeasePool backed by store. dataSource and
+// etcdDir may be left as the zero value when store isn't etcd-backed;
+// doing so simply means static leases and Watch events aren't available
+// on this pool. ICMP foreign-host cool-downs go through store and work
+// on every backend.
+func NewLeasePool(store LeaseStore, dataSource etcd.KeysAPI, etcdDir string, startIP net.IP, rangeLen int, expireDuration time.Duration, icmpTimeout time.Duration) (*LeasePool, error) {
 	pool := &LeasePool{
+		store:          store,
+		dataSource:     dataSource,
 		etcdDir:        etcdDir,
 		startIP:        startIP,
 		expireDuration: expireDuration,
 		rangeLen:       rangeLen,
-		dataSource:     dataSource,
+		ICMPTimeout:    icmpTimeout,
 	}
 	return pool, nil
 }
 
-// Store will store the lease in etcd
+// Store will store the lease
 func (p *LeasePool) Store(lease Lease) error {
-	p.dataLock.Lock()
-	defer p.dataLock.Unlock()
-	data, err := json.Marshal(lease)
-	if err != nil {
-		return err
-	}
-	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
-	defer cancel()
-	_, err = p.dataSource.Set(ctx, path.Join(p.etcdDir, "/leases", lease.IP.String()), string(data), nil)
-	return err
+	return p.store.Put(lease)
 }
 
-// Leases returns map binary.BigEndian.Uint32(IP) and Lease of all assigned leases
-func (p *LeasePool) Leases() (map[string]Lease, error) {
-	p.dataLock.Lock()
-	defer p.dataLock.Unlock()
-	leases := make(map[string]Lease, 10)
-
-	ctxGet, cancelGet := context.WithTimeout(context.Background(), 2*time.Second)
-	defer cancelGet()
-	response, err := p.dataSource.Get(ctxGet, path.Join(p.etcdDir, "/leases"), &etcd.GetOptions{Recursive: true})
+// markForeignHost records that ip answered an ICMP echo despite not
+// being in our lease table, so Assign skips it for foreignHostCoolDown
+// instead of immediately handing it to a client. It's stored as an
+// ordinary (soon-to-expire) Lease, so the normal expired-lease reuse
+// path in Assign naturally releases it again after the cool-down.
+func (p *LeasePool) markForeignHost(ip net.IP) error {
+	return p.store.Put(newLease(foreignHostNic, ip, foreignHostCoolDown, nil))
+}
 
+// Leases returns map of ip.String() to Lease of all assigned leases
+func (p *LeasePool) Leases() (map[string]Lease, error) {
+	list, err := p.store.List()
 	if err != nil {
-		etcdError, found := err.(etcd.Error)
-		if found && etcdError.Code == etcd.ErrorCodeKeyNotFound {
-			// handle key not found
-			ctxSet, cancelSet := context.WithTimeout(context.Background(), 2*time.Second)
-			defer cancelSet()
-			_, err := p.dataSource.Set(ctxSet, path.Join(p.etcdDir, "/leases"), "", &etcd.SetOptions{Dir: true})
-			if err != nil {
-				return nil, err
-			}
-			return leases, nil
-		}
 		return nil, err
 	}
-	for i := range response.Node.Nodes {
-		var lease Lease
-		err := json.Unmarshal([]byte(response.Node.Nodes[i].Value), &lease)
-		if err == nil {
-			leases[lease.IP.String()] = lease
-		} else {
-			return nil, ErrFoundInvalidLease
-		}
+	leases := make(map[string]Lease, len(list))
+	for _, lease := range list {
+		leases[lease.IP.String()] = lease
 	}
 	return leases, nil
 }
 
 // Reset will delete all the assigned leases
 func (p *LeasePool) Reset() error {
-	p.dataLock.Lock()
-	defer p.dataLock.Unlock()
-
-	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
-	defer cancel()
-	_, err := p.dataSource.Delete(ctx, path.Join(p.etcdDir, "/leases"), &etcd.DeleteOptions{Dir: true, Recursive: true})
-	if err != nil {
-		etcdError, found := err.(etcd.Error)
-		if found && etcdError.Code == etcd.ErrorCodeKeyNotFound {
-			return nil
-		}
-		return err
-	}
-	return nil
+	return p.store.Reset()
 }
 
 // Assign will find an IP for the specified nic
 func (p *LeasePool) Assign(nic string) (net.IP, error) {
 	p.assignLock.Lock()
 	defer p.assignLock.Unlock()
+
+	if static, err := p.staticLeaseForNic(nic); err != nil {
+		return nil, err
+	} else if static != nil {
+		return static.IP, nil
+	}
+
 	leases, err := p.Leases()
 	if err != nil {
 		return nil, err
@@ -157,13 +142,39 @@ func (p *LeasePool) Assign(nic string) (net.IP, error) {
 	for i := 0; i < p.rangeLen; i++ {
 		ip := dhcp4.IPAdd(p.startIP, i)
 		_, exists := leases[ip.String()]
-		if !exists {
-			err := p.Store(newLease(nic, ip, p.expireDuration, nil))
+		if exists {
+			continue
+		}
+		if static, err := p.staticLeaseForIP(ip); err != nil {
+			return nil, err
+		} else if static != nil {
+			continue
+		}
+		if p.ICMPTimeout > 0 {
+			inUse, err := pingIP(ip, p.ICMPTimeout)
 			if err != nil {
-				return nil, err
+				// A probe failure (e.g. no CAP_NET_RAW to open a raw
+				// ICMP socket) shouldn't turn into a total DHCP outage:
+				// treat it as "couldn't check", not "in use", and fall
+				// through to handing the address out as usual.
+				inUse = false
+			}
+			if inUse {
+				p.markForeignHost(ip)
+				continue
 			}
-			return ip, nil
 		}
+		// TryCreate rather than Store: two Blacksmith instances can
+		// race to this point having both read the same unseen ip, and
+		// only one of them should win it.
+		created, err := p.store.TryCreate(newLease(nic, ip, p.expireDuration, nil))
+		if err != nil {
+			return nil, err
+		}
+		if !created {
+			continue
+		}
+		return ip, nil
 	}
 	// find an expired ip
 	now := time.Now()
@@ -179,6 +190,21 @@ func (p *LeasePool) Assign(nic string) (net.IP, error) {
 func (p *LeasePool) Request(nic string, currentIP net.IP) (net.IP, error) {
 	p.assignLock.Lock()
 	defer p.assignLock.Unlock()
+
+	if static, err := p.staticLeaseForNic(nic); err != nil {
+		return nil, err
+	} else if static != nil {
+		if static.IP.Equal(currentIP) {
+			return currentIP, nil
+		}
+		return nil, ErrRefreshNoMatch
+	}
+	if static, err := p.staticLeaseForIP(currentIP); err != nil {
+		return nil, err
+	} else if static != nil && static.Nic != nic {
+		return nil, ErrRefreshNoMatch
+	}
+
 	leases, err := p.Leases()
 	if err != nil {
 		return nil, err
@@ -203,4 +229,4 @@ func (p *LeasePool) Request(nic string, currentIP net.IP) (net.IP, error) {
 		return currentIP, nil
 	}
 	return nil, ErrRefreshNoMatch
-}
\ No newline at end of file
+}
@@ -0,0 +1,247 @@
+package dhcp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"path"
+	"sync"
+	"time"
+
+	etcd "github.com/coreos/etcd/client"
+)
+
+// TODO share more code with the v4 LeasePool once the common bits
+// 		(store/list/expire) are factored out
+
+var (
+	ErrLeasePoolV6IsFull = errors.New("there is no empty IPv6 address at the moment")
+)
+
+// ClientID identifies a DHCPv6 client by its DUID and the IAID of the
+// identity association it requested an address for.
+type ClientID struct {
+	DUID string
+	IAID uint32
+}
+
+// LeaseV6 is the v6 counterpart of Lease, keyed by DUID+IAID instead of
+// a MAC address.
+type LeaseV6 struct {
+	DUID          string
+	IAID          uint32
+	IP            net.IP
+	FirstAssigned time.Time
+	LastAssigned  time.Time
+	ExpireTime    time.Time
+}
+
+func newLeaseV6(client ClientID, ip net.IP, expireDuration time.Duration, firstAssigned *time.Time) LeaseV6 {
+	now := time.Now()
+	lease := LeaseV6{
+		DUID:         client.DUID,
+		IAID:         client.IAID,
+		IP:           ip,
+		LastAssigned: now,
+		ExpireTime:   now.Add(expireDuration),
+	}
+	if firstAssigned == nil {
+		lease.FirstAssigned = now
+	} else {
+		lease.FirstAssigned = *firstAssigned
+	}
+	return lease
+}
+
+// LeasePoolV6 manages IA_NA assignments for DHCPv6 clients, mirroring
+// LeasePool but keyed by DUID+IAID rather than MAC address and stored
+// under a separate etcd subtree so the two families never collide.
+type LeasePoolV6 struct {
+	etcdDir        string
+	startIP        net.IP
+	rangeLen       int
+	expireDuration time.Duration
+	dataSource     etcd.KeysAPI
+	dataLock       sync.Mutex
+	assignLock     sync.Mutex
+}
+
+func NewLeasePoolV6(dataSource etcd.KeysAPI, etcdDir string, startIP net.IP, rangeLen int, expireDuration time.Duration) (*LeasePoolV6, error) {
+	pool := &LeasePoolV6{
+		etcdDir:        etcdDir,
+		startIP:        startIP,
+		expireDuration: expireDuration,
+		rangeLen:       rangeLen,
+		dataSource:     dataSource,
+	}
+	return pool, nil
+}
+
+func ipv6Add(ip net.IP, add int) net.IP {
+	ip = ip.To16()
+	result := make(net.IP, len(ip))
+	copy(result, ip)
+	for i := len(result) - 1; i >= 0 && add > 0; i-- {
+		sum := int(result[i]) + add
+		result[i] = byte(sum & 0xff)
+		add = sum >> 8
+	}
+	return result
+}
+
+// Store will store the lease in etcd under /leases6
+func (p *LeasePoolV6) Store(lease LeaseV6) error {
+	p.dataLock.Lock()
+	defer p.dataLock.Unlock()
+	data, err := json.Marshal(lease)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	_, err = p.dataSource.Set(ctx, path.Join(p.etcdDir, "/leases6", lease.IP.String()), string(data), nil)
+	return err
+}
+
+// tryCreate stores lease under /leases6 only if that IP has no lease
+// yet, so two Blacksmith instances racing to assign the same unseen
+// address can't both win it.
+func (p *LeasePoolV6) tryCreate(lease LeaseV6) (bool, error) {
+	p.dataLock.Lock()
+	defer p.dataLock.Unlock()
+	data, err := json.Marshal(lease)
+	if err != nil {
+		return false, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	_, err = p.dataSource.Set(ctx, path.Join(p.etcdDir, "/leases6", lease.IP.String()), string(data), &etcd.SetOptions{PrevExist: etcd.PrevNoExist})
+	if err != nil {
+		etcdError, found := err.(etcd.Error)
+		if found && etcdError.Code == etcd.ErrorCodeNodeExist {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// Leases returns a map of all assigned v6 leases, keyed by IP string.
+func (p *LeasePoolV6) Leases() (map[string]LeaseV6, error) {
+	p.dataLock.Lock()
+	defer p.dataLock.Unlock()
+	leases := make(map[string]LeaseV6, 10)
+
+	ctxGet, cancelGet := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancelGet()
+	response, err := p.dataSource.Get(ctxGet, path.Join(p.etcdDir, "/leases6"), &etcd.GetOptions{Recursive: true})
+
+	if err != nil {
+		etcdError, found := err.(etcd.Error)
+		if found && etcdError.Code == etcd.ErrorCodeKeyNotFound {
+			ctxSet, cancelSet := context.WithTimeout(context.Background(), 2*time.Second)
+			defer cancelSet()
+			_, err := p.dataSource.Set(ctxSet, path.Join(p.etcdDir, "/leases6"), "", &etcd.SetOptions{Dir: true})
+			if err != nil {
+				return nil, err
+			}
+			return leases, nil
+		}
+		return nil, err
+	}
+	for i := range response.Node.Nodes {
+		var lease LeaseV6
+		err := json.Unmarshal([]byte(response.Node.Nodes[i].Value), &lease)
+		if err == nil {
+			leases[lease.IP.String()] = lease
+		} else {
+			return nil, ErrFoundInvalidLease
+		}
+	}
+	return leases, nil
+}
+
+// Assign finds (or reuses) an IPv6 address for the given client identity.
+func (p *LeasePoolV6) Assign(client ClientID) (net.IP, error) {
+	p.assignLock.Lock()
+	defer p.assignLock.Unlock()
+	leases, err := p.Leases()
+	if err != nil {
+		return nil, err
+	}
+	// try to find by DUID+IAID
+	for _, lease := range leases {
+		if lease.DUID == client.DUID && lease.IAID == client.IAID {
+			p.Store(newLeaseV6(client, lease.IP, p.expireDuration, &lease.FirstAssigned))
+			return lease.IP, nil
+		}
+	}
+	// find an unseen ip
+	for i := 0; i < p.rangeLen; i++ {
+		ip := ipv6Add(p.startIP, i)
+		_, exists := leases[ip.String()]
+		if exists {
+			continue
+		}
+		// tryCreate rather than Store: two Blacksmith instances can
+		// race to this point having both read the same unseen ip, and
+		// only one of them should win it.
+		created, err := p.tryCreate(newLeaseV6(client, ip, p.expireDuration, nil))
+		if err != nil {
+			return nil, err
+		}
+		if !created {
+			continue
+		}
+		return ip, nil
+	}
+	// find an expired ip
+	now := time.Now()
+	for _, lease := range leases {
+		if lease.ExpireTime.Before(now) {
+			p.Store(newLeaseV6(client, lease.IP, p.expireDuration, nil))
+			return lease.IP, nil
+		}
+	}
+	return nil, ErrLeasePoolV6IsFull
+}
+
+// Request confirms (Renew/Rebind) an already-advertised IPv6 address for
+// the given client identity.
+func (p *LeasePoolV6) Request(client ClientID, currentIP net.IP) (net.IP, error) {
+	p.assignLock.Lock()
+	defer p.assignLock.Unlock()
+	leases, err := p.Leases()
+	if err != nil {
+		return nil, err
+	}
+	lease, exists := leases[currentIP.String()]
+	if exists && lease.DUID == client.DUID && lease.IAID == client.IAID {
+		p.Store(newLeaseV6(client, lease.IP, p.expireDuration, &lease.FirstAssigned))
+		return lease.IP, nil
+	} else if !exists {
+		p.Store(newLeaseV6(client, currentIP, p.expireDuration, nil))
+		return currentIP, nil
+	}
+	return nil, ErrRefreshNoMatch
+}
+
+// Release removes the lease bound to the given client identity, if any.
+func (p *LeasePoolV6) Release(client ClientID) error {
+	leases, err := p.Leases()
+	if err != nil {
+		return err
+	}
+	for _, lease := range leases {
+		if lease.DUID == client.DUID && lease.IAID == client.IAID {
+			p.dataLock.Lock()
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			_, err := p.dataSource.Delete(ctx, path.Join(p.etcdDir, "/leases6", lease.IP.String()), nil)
+			cancel()
+			p.dataLock.Unlock()
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package dhcp
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestLeasePoolV6AssignUnseenAndReuse(t *testing.T) {
+	pool, err := NewLeasePoolV6(newFakeKeysAPI(), "aghajoon", net.ParseIP("fd00::10"), 4, time.Hour)
+	if err != nil {
+		t.Fatalf("NewLeasePoolV6: %v", err)
+	}
+
+	clientA := ClientID{DUID: "0001aabbcc", IAID: 1}
+	ip, err := pool.Assign(clientA)
+	if err != nil {
+		t.Fatalf("Assign: %v", err)
+	}
+	if !ip.Equal(net.ParseIP("fd00::10")) {
+		t.Fatalf("Assign = %s, want fd00::10", ip)
+	}
+
+	// Re-assigning the same DUID+IAID should return the same address.
+	again, err := pool.Assign(clientA)
+	if err != nil {
+		t.Fatalf("Assign (repeat): %v", err)
+	}
+	if !again.Equal(ip) {
+		t.Fatalf("Assign (repeat) = %s, want %s", again, ip)
+	}
+
+	clientB := ClientID{DUID: "0001ddeeff", IAID: 1}
+	ipB, err := pool.Assign(clientB)
+	if err != nil {
+		t.Fatalf("Assign (clientB): %v", err)
+	}
+	if ipB.Equal(ip) {
+		t.Fatal("two distinct clients got the same IPv6 address")
+	}
+}
+
+func TestLeasePoolV6AssignReusesExpired(t *testing.T) {
+	pool, err := NewLeasePoolV6(newFakeKeysAPI(), "aghajoon", net.ParseIP("fd00::20"), 1, -time.Hour)
+	if err != nil {
+		t.Fatalf("NewLeasePoolV6: %v", err)
+	}
+
+	first := ClientID{DUID: "0001111111", IAID: 1}
+	ip, err := pool.Assign(first)
+	if err != nil {
+		t.Fatalf("Assign: %v", err)
+	}
+
+	second := ClientID{DUID: "0001222222", IAID: 1}
+	reused, err := pool.Assign(second)
+	if err != nil {
+		t.Fatalf("Assign (second): %v", err)
+	}
+	if !reused.Equal(ip) {
+		t.Fatalf("Assign didn't reuse the expired lease: got %s, want %s", reused, ip)
+	}
+}
+
+func TestLeasePoolV6AssignFullReturnsErr(t *testing.T) {
+	pool, err := NewLeasePoolV6(newFakeKeysAPI(), "aghajoon", net.ParseIP("fd00::40"), 1, time.Hour)
+	if err != nil {
+		t.Fatalf("NewLeasePoolV6: %v", err)
+	}
+	if _, err := pool.Assign(ClientID{DUID: "0001000001", IAID: 1}); err != nil {
+		t.Fatalf("Assign: %v", err)
+	}
+	if _, err := pool.Assign(ClientID{DUID: "0001000002", IAID: 1}); err != ErrLeasePoolV6IsFull {
+		t.Fatalf("Assign (pool full) = %v, want ErrLeasePoolV6IsFull", err)
+	}
+}
+
+func TestLeasePoolV6RequestAndRelease(t *testing.T) {
+	pool, err := NewLeasePoolV6(newFakeKeysAPI(), "aghajoon", net.ParseIP("fd00::30"), 4, time.Hour)
+	if err != nil {
+		t.Fatalf("NewLeasePoolV6: %v", err)
+	}
+
+	client := ClientID{DUID: "0001abcabc", IAID: 7}
+	ip := net.ParseIP("fd00::31")
+	confirmed, err := pool.Request(client, ip)
+	if err != nil {
+		t.Fatalf("Request: %v", err)
+	}
+	if !confirmed.Equal(ip) {
+		t.Fatalf("Request = %s, want %s", confirmed, ip)
+	}
+
+	// Confirming again with the same identity (Renew/Rebind) should succeed.
+	if _, err := pool.Request(client, ip); err != nil {
+		t.Fatalf("Request (repeat): %v", err)
+	}
+
+	other := ClientID{DUID: "0001fedfed", IAID: 9}
+	if _, err := pool.Request(other, ip); err != ErrRefreshNoMatch {
+		t.Fatalf("Request (conflicting client) = %v, want ErrRefreshNoMatch", err)
+	}
+
+	if err := pool.Release(client); err != nil {
+		t.Fatalf("Release: %v", err)
+	}
+	leases, err := pool.Leases()
+	if err != nil {
+		t.Fatalf("Leases: %v", err)
+	}
+	if _, exists := leases[ip.String()]; exists {
+		t.Fatal("Release left the lease in place")
+	}
+}
@@ -0,0 +1,29 @@
+package dhcp
+
+import (
+	"errors"
+	"net"
+)
+
+// ErrLeaseNotFound is returned by LeaseStore.Get when no lease is
+// stored for the given IP.
+var ErrLeaseNotFound = errors.New("lease not found")
+
+// LeaseStore is the storage backend a LeasePool hands its Lease records
+// to. The etcd-backed implementation is what Blacksmith has always
+// used; LeaseStore exists so single-node deployments that don't want to
+// run etcd can use a local BoltDB file instead, and so tests can use a
+// plain in-memory map.
+type LeaseStore interface {
+	Get(ip net.IP) (Lease, error)
+	Put(lease Lease) error
+	Delete(ip net.IP) error
+	List() ([]Lease, error)
+	Reset() error
+
+	// TryCreate stores lease only if nothing is currently stored for
+	// its IP, atomically. It's the CAS primitive Assign needs: without
+	// it, two Blacksmith instances racing on the same unseen IP could
+	// both read "not present" and both hand it out.
+	TryCreate(lease Lease) (created bool, err error)
+}
@@ -0,0 +1,106 @@
+package dhcp
+
+import (
+	"encoding/json"
+	"net"
+
+	bolt "github.com/coreos/bbolt"
+)
+
+// leasesBucket is the single bbolt bucket boltLeaseStore keeps all
+// leases in, keyed by IP string.
+var leasesBucket = []byte("leases")
+
+// boltLeaseStore is a LeaseStore backed by a single BoltDB file, for
+// single-node deployments that don't want to run etcd.
+type boltLeaseStore struct {
+	db *bolt.DB
+}
+
+// NewBoltLeaseStore opens (creating if necessary) a BoltDB file at path
+// and returns it as a LeaseStore.
+func NewBoltLeaseStore(path string) (LeaseStore, error) {
+	db, err := bolt.Open(path, 0600, nil)
+	if err != nil {
+		return nil, err
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(leasesBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &boltLeaseStore{db: db}, nil
+}
+
+func (s *boltLeaseStore) Get(ip net.IP) (Lease, error) {
+	var lease Lease
+	err := s.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(leasesBucket).Get([]byte(ip.String()))
+		if data == nil {
+			return ErrLeaseNotFound
+		}
+		return json.Unmarshal(data, &lease)
+	})
+	return lease, err
+}
+
+func (s *boltLeaseStore) Put(lease Lease) error {
+	data, err := json.Marshal(lease)
+	if err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(leasesBucket).Put([]byte(lease.IP.String()), data)
+	})
+}
+
+func (s *boltLeaseStore) TryCreate(lease Lease) (bool, error) {
+	data, err := json.Marshal(lease)
+	if err != nil {
+		return false, err
+	}
+	created := false
+	err = s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(leasesBucket)
+		if bucket.Get([]byte(lease.IP.String())) != nil {
+			return nil
+		}
+		created = true
+		return bucket.Put([]byte(lease.IP.String()), data)
+	})
+	return created, err
+}
+
+func (s *boltLeaseStore) Delete(ip net.IP) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(leasesBucket).Delete([]byte(ip.String()))
+	})
+}
+
+func (s *boltLeaseStore) List() ([]Lease, error) {
+	var leases []Lease
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(leasesBucket).ForEach(func(k, v []byte) error {
+			var lease Lease
+			if err := json.Unmarshal(v, &lease); err != nil {
+				return ErrFoundInvalidLease
+			}
+			leases = append(leases, lease)
+			return nil
+		})
+	})
+	return leases, err
+}
+
+func (s *boltLeaseStore) Reset() error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		if err := tx.DeleteBucket(leasesBucket); err != nil {
+			return err
+		}
+		_, err := tx.CreateBucket(leasesBucket)
+		return err
+	})
+}
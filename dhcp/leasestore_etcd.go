@@ -0,0 +1,132 @@
+package dhcp
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"path"
+	"time"
+
+	etcd "github.com/coreos/etcd/client"
+)
+
+// etcdLeaseStore is the original etcd-backed LeaseStore: every lease is
+// a JSON value under etcdDir+"/leases".
+type etcdLeaseStore struct {
+	dataSource etcd.KeysAPI
+	etcdDir    string
+}
+
+// NewEtcdLeaseStore wraps an etcd KeysAPI as a LeaseStore, storing
+// leases under etcdDir+"/leases".
+func NewEtcdLeaseStore(dataSource etcd.KeysAPI, etcdDir string) LeaseStore {
+	return &etcdLeaseStore{dataSource: dataSource, etcdDir: etcdDir}
+}
+
+func (s *etcdLeaseStore) key(ip net.IP) string {
+	return path.Join(s.etcdDir, "/leases", ip.String())
+}
+
+func (s *etcdLeaseStore) Get(ip net.IP) (Lease, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	response, err := s.dataSource.Get(ctx, s.key(ip), nil)
+	if err != nil {
+		etcdError, found := err.(etcd.Error)
+		if found && etcdError.Code == etcd.ErrorCodeKeyNotFound {
+			return Lease{}, ErrLeaseNotFound
+		}
+		return Lease{}, err
+	}
+	var lease Lease
+	if err := json.Unmarshal([]byte(response.Node.Value), &lease); err != nil {
+		return Lease{}, ErrFoundInvalidLease
+	}
+	return lease, nil
+}
+
+func (s *etcdLeaseStore) Put(lease Lease) error {
+	data, err := json.Marshal(lease)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	_, err = s.dataSource.Set(ctx, s.key(lease.IP), string(data), nil)
+	return err
+}
+
+func (s *etcdLeaseStore) TryCreate(lease Lease) (bool, error) {
+	data, err := json.Marshal(lease)
+	if err != nil {
+		return false, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	_, err = s.dataSource.Set(ctx, s.key(lease.IP), string(data), &etcd.SetOptions{PrevExist: etcd.PrevNoExist})
+	if err != nil {
+		etcdError, found := err.(etcd.Error)
+		if found && etcdError.Code == etcd.ErrorCodeNodeExist {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+func (s *etcdLeaseStore) Delete(ip net.IP) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	_, err := s.dataSource.Delete(ctx, s.key(ip), nil)
+	if err != nil {
+		etcdError, found := err.(etcd.Error)
+		if found && etcdError.Code == etcd.ErrorCodeKeyNotFound {
+			return nil
+		}
+		return err
+	}
+	return nil
+}
+
+func (s *etcdLeaseStore) List() ([]Lease, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	response, err := s.dataSource.Get(ctx, path.Join(s.etcdDir, "/leases"), &etcd.GetOptions{Recursive: true})
+	if err != nil {
+		etcdError, found := err.(etcd.Error)
+		if found && etcdError.Code == etcd.ErrorCodeKeyNotFound {
+			ctxSet, cancelSet := context.WithTimeout(context.Background(), 2*time.Second)
+			defer cancelSet()
+			_, err := s.dataSource.Set(ctxSet, path.Join(s.etcdDir, "/leases"), "", &etcd.SetOptions{Dir: true})
+			if err != nil {
+				return nil, err
+			}
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	leases := make([]Lease, 0, len(response.Node.Nodes))
+	for i := range response.Node.Nodes {
+		var lease Lease
+		if err := json.Unmarshal([]byte(response.Node.Nodes[i].Value), &lease); err != nil {
+			return nil, ErrFoundInvalidLease
+		}
+		leases = append(leases, lease)
+	}
+	return leases, nil
+}
+
+func (s *etcdLeaseStore) Reset() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	_, err := s.dataSource.Delete(ctx, path.Join(s.etcdDir, "/leases"), &etcd.DeleteOptions{Dir: true, Recursive: true})
+	if err != nil {
+		etcdError, found := err.(etcd.Error)
+		if found && etcdError.Code == etcd.ErrorCodeKeyNotFound {
+			return nil
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package dhcp
+
+import (
+	"net"
+	"sync"
+)
+
+// memoryLeaseStore is an in-memory LeaseStore, for tests that don't
+// want to stand up an etcd instance.
+type memoryLeaseStore struct {
+	lock   sync.Mutex
+	leases map[string]Lease
+}
+
+// NewMemoryLeaseStore returns an empty in-memory LeaseStore.
+func NewMemoryLeaseStore() LeaseStore {
+	return &memoryLeaseStore{leases: make(map[string]Lease)}
+}
+
+func (s *memoryLeaseStore) Get(ip net.IP) (Lease, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	lease, ok := s.leases[ip.String()]
+	if !ok {
+		return Lease{}, ErrLeaseNotFound
+	}
+	return lease, nil
+}
+
+func (s *memoryLeaseStore) Put(lease Lease) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.leases[lease.IP.String()] = lease
+	return nil
+}
+
+func (s *memoryLeaseStore) TryCreate(lease Lease) (bool, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if _, exists := s.leases[lease.IP.String()]; exists {
+		return false, nil
+	}
+	s.leases[lease.IP.String()] = lease
+	return true, nil
+}
+
+func (s *memoryLeaseStore) Delete(ip net.IP) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	delete(s.leases, ip.String())
+	return nil
+}
+
+func (s *memoryLeaseStore) List() ([]Lease, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	leases := make([]Lease, 0, len(s.leases))
+	for _, lease := range s.leases {
+		leases = append(leases, lease)
+	}
+	return leases, nil
+}
+
+func (s *memoryLeaseStore) Reset() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.leases = make(map[string]Lease)
+	return nil
+}
@@ -0,0 +1,64 @@
+package dhcp
+
+import (
+	"fmt"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestMemoryLeaseStoreTryCreate(t *testing.T) {
+	store := NewMemoryLeaseStore()
+	ip := net.ParseIP("10.0.0.5")
+	lease := newLease("aa:bb:cc:dd:ee:ff", ip, time.Hour, nil)
+
+	created, err := store.TryCreate(lease)
+	if err != nil {
+		t.Fatalf("TryCreate: %v", err)
+	}
+	if !created {
+		t.Fatal("expected TryCreate to win on an empty store")
+	}
+
+	other := newLease("11:22:33:44:55:66", ip, time.Hour, nil)
+	created, err = store.TryCreate(other)
+	if err != nil {
+		t.Fatalf("TryCreate: %v", err)
+	}
+	if created {
+		t.Fatal("expected TryCreate to lose once the IP is taken")
+	}
+
+	got, err := store.Get(ip)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if got.Nic != lease.Nic {
+		t.Fatalf("Get returned the loser's lease: got nic %q, want %q", got.Nic, lease.Nic)
+	}
+}
+
+func TestMemoryLeaseStoreTryCreateConcurrent(t *testing.T) {
+	store := NewMemoryLeaseStore()
+	ip := net.ParseIP("10.0.0.9")
+
+	const racers = 50
+	wins := make(chan bool, racers)
+	for i := 0; i < racers; i++ {
+		nic := fmt.Sprintf("nic-%d", i)
+		go func(nic string) {
+			created, err := store.TryCreate(newLease(nic, ip, time.Hour, nil))
+			wins <- err == nil && created
+		}(nic)
+	}
+
+	created := 0
+	for i := 0; i < racers; i++ {
+		if <-wins {
+			created++
+		}
+	}
+	if created != 1 {
+		t.Fatalf("expected exactly one TryCreate to win a race for the same IP, got %d", created)
+	}
+}
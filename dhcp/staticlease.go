@@ -0,0 +1,180 @@
+package dhcp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"path"
+	"time"
+
+	etcd "github.com/coreos/etcd/client"
+	"github.com/krolaw/dhcp4"
+)
+
+// TODO surface these through the HTTP API once a web subsystem lands in
+// 		this tree; for now they're reachable only via the LeasePool API.
+
+var (
+	ErrStaticOutOfRange  = errors.New("static lease IP is outside the pool's configured range")
+	ErrStaticMACConflict = errors.New("nic already has a static lease")
+	ErrStaticIPConflict  = errors.New("ip is already statically reserved for another nic")
+	// ErrStaticBackendUnavailable is returned when a pool was built
+	// with a non-etcd LeaseStore: static leases still live in etcd
+	// directly and have no bolt/memory equivalent yet.
+	ErrStaticBackendUnavailable = errors.New("static leases require the etcd lease backend")
+)
+
+// StaticLease is a MAC -> IP (and optional hostname) pin that Assign and
+// Request honor before ever touching the dynamic pool, and that the
+// expiry sweep never reclaims.
+type StaticLease struct {
+	Nic      string
+	IP       net.IP
+	Hostname string
+}
+
+// AddStaticLease reserves ip for nic. ip must fall within the pool's
+// configured range, and neither nic nor ip may already be statically
+// bound to something else.
+func (p *LeasePool) AddStaticLease(nic string, ip net.IP, hostname string) error {
+	if p.dataSource == nil {
+		return ErrStaticBackendUnavailable
+	}
+	if !p.inRange(ip) {
+		return ErrStaticOutOfRange
+	}
+
+	// assignLock also guards the static-lease check-then-write below, so
+	// two concurrent Add/RemoveStaticLease calls can't both pass the
+	// conflict checks before either writes.
+	p.assignLock.Lock()
+	defer p.assignLock.Unlock()
+
+	statics, err := p.StaticLeases()
+	if err != nil {
+		return err
+	}
+	for _, s := range statics {
+		if s.Nic == nic {
+			return ErrStaticMACConflict
+		}
+		if s.IP.Equal(ip) {
+			return ErrStaticIPConflict
+		}
+	}
+
+	lease := StaticLease{Nic: nic, IP: ip, Hostname: hostname}
+	data, err := json.Marshal(lease)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	_, err = p.dataSource.Set(ctx, path.Join(p.etcdDir, "/leases-static", nic), string(data), nil)
+	return err
+}
+
+// RemoveStaticLease removes the static reservation for nic, if any.
+func (p *LeasePool) RemoveStaticLease(nic string) error {
+	if p.dataSource == nil {
+		return ErrStaticBackendUnavailable
+	}
+	p.assignLock.Lock()
+	defer p.assignLock.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	_, err := p.dataSource.Delete(ctx, path.Join(p.etcdDir, "/leases-static", nic), nil)
+	if err != nil {
+		etcdError, found := err.(etcd.Error)
+		if found && etcdError.Code == etcd.ErrorCodeKeyNotFound {
+			return nil
+		}
+		return err
+	}
+	return nil
+}
+
+// StaticLeases returns every static reservation currently configured.
+func (p *LeasePool) StaticLeases() ([]StaticLease, error) {
+	if p.dataSource == nil {
+		return nil, nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	response, err := p.dataSource.Get(ctx, path.Join(p.etcdDir, "/leases-static"), &etcd.GetOptions{Recursive: true})
+	if err != nil {
+		etcdError, found := err.(etcd.Error)
+		if found && etcdError.Code == etcd.ErrorCodeKeyNotFound {
+			ctxSet, cancelSet := context.WithTimeout(context.Background(), 2*time.Second)
+			defer cancelSet()
+			_, err := p.dataSource.Set(ctxSet, path.Join(p.etcdDir, "/leases-static"), "", &etcd.SetOptions{Dir: true})
+			if err != nil {
+				return nil, err
+			}
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	statics := make([]StaticLease, 0, len(response.Node.Nodes))
+	for i := range response.Node.Nodes {
+		var lease StaticLease
+		if err := json.Unmarshal([]byte(response.Node.Nodes[i].Value), &lease); err != nil {
+			return nil, ErrFoundInvalidLease
+		}
+		statics = append(statics, lease)
+	}
+	return statics, nil
+}
+
+// staticLeaseForNic returns the static reservation bound to nic, if any.
+func (p *LeasePool) staticLeaseForNic(nic string) (*StaticLease, error) {
+	statics, err := p.StaticLeases()
+	if err != nil {
+		return nil, err
+	}
+	for i := range statics {
+		if statics[i].Nic == nic {
+			return &statics[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// staticLeaseForIP returns the static reservation pinned to ip, if any.
+func (p *LeasePool) staticLeaseForIP(ip net.IP) (*StaticLease, error) {
+	statics, err := p.StaticLeases()
+	if err != nil {
+		return nil, err
+	}
+	for i := range statics {
+		if statics[i].IP.Equal(ip) {
+			return &statics[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// HostnameForIP returns the hostname configured for a statically leased
+// ip. Nothing in this tree calls it, so no DHCP reply carries the
+// hostname as Option 12.
+func (p *LeasePool) HostnameForIP(ip net.IP) (string, bool) {
+	lease, err := p.staticLeaseForIP(ip)
+	if err != nil || lease == nil || lease.Hostname == "" {
+		return "", false
+	}
+	return lease.Hostname, true
+}
+
+// inRange reports whether ip falls within the pool's configured
+// start/range.
+func (p *LeasePool) inRange(ip net.IP) bool {
+	for i := 0; i < p.rangeLen; i++ {
+		if dhcp4.IPAdd(p.startIP, i).Equal(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,211 @@
+package dhcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path"
+	"time"
+
+	etcd "github.com/coreos/etcd/client"
+)
+
+// LeaseEventType describes what happened to a Lease in a LeaseEvent.
+type LeaseEventType int
+
+const (
+	LeaseAssigned LeaseEventType = iota
+	LeaseRenewed
+	LeaseExpired
+	LeaseReleased
+	LeaseDeleted
+)
+
+func (t LeaseEventType) String() string {
+	switch t {
+	case LeaseAssigned:
+		return "Assigned"
+	case LeaseRenewed:
+		return "Renewed"
+	case LeaseExpired:
+		return "Expired"
+	case LeaseReleased:
+		return "Released"
+	case LeaseDeleted:
+		return "Deleted"
+	default:
+		return "Unknown"
+	}
+}
+
+// LeaseEvent is emitted by LeasePool.Watch whenever a lease changes.
+// Prev is the lease's state before the change, when known.
+type LeaseEvent struct {
+	Type  LeaseEventType
+	Lease Lease
+	Prev  *Lease
+}
+
+// defaultReapInterval is how often the background reaper started by
+// Watch scans for expired leases when the caller doesn't specify one.
+const defaultReapInterval = 1 * time.Minute
+
+// Watch streams lease churn on /leases as it happens. Writes made via
+// Store show up as Assigned (first time) or Renewed (refresh); explicit
+// removals show up as Released (voluntary, before expiry) or Deleted
+// (e.g. Reset wiping the whole tree). It also starts a background
+// reaper that, every reapInterval (defaultReapInterval if <= 0), scans
+// for leases whose ExpireTime has passed, deletes them from the lease
+// store -- which Blacksmith otherwise never does, letting /leases grow
+// unboundedly -- and emits the corresponding Expired event.
+//
+// The returned channel is closed when ctx is done. On a pool without an
+// etcd dataSource it is closed at once without any events, and only the
+// reaper runs.
+func (p *LeasePool) Watch(ctx context.Context, reapInterval time.Duration) <-chan LeaseEvent {
+	if reapInterval <= 0 {
+		reapInterval = defaultReapInterval
+	}
+	out := make(chan LeaseEvent)
+	if p.dataSource == nil {
+		// Lease-change events ride etcd's own Watcher API; backends
+		// other than etcd don't have an equivalent yet. Still run the
+		// reaper so leases don't leak on those backends, just without
+		// a matching Expired event.
+		go p.reapExpired(ctx, reapInterval)
+		close(out)
+		return out
+	}
+	go func() {
+		defer close(out)
+		p.watchStore(ctx, out)
+	}()
+	go p.reapExpired(ctx, reapInterval)
+	return out
+}
+
+func (p *LeasePool) watchStore(ctx context.Context, out chan<- LeaseEvent) {
+	watcher := p.dataSource.Watcher(path.Join(p.etcdDir, "/leases"), &etcd.WatcherOptions{Recursive: true})
+	for {
+		resp, err := watcher.Next(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			continue
+		}
+
+		if resp.Node != nil && resp.Node.Dir {
+			// Reset() wiping the whole /leases tree. Other directory
+			// events, such as List recreating /leases, aren't lease
+			// changes.
+			if resp.Action != "delete" {
+				continue
+			}
+			select {
+			case out <- LeaseEvent{Type: LeaseDeleted}:
+			case <-ctx.Done():
+				return
+			}
+			continue
+		}
+
+		var lease Lease
+		if resp.Node != nil && resp.Node.Value != "" {
+			if err := json.Unmarshal([]byte(resp.Node.Value), &lease); err != nil {
+				continue
+			}
+		}
+		var prev *Lease
+		if resp.PrevNode != nil && resp.PrevNode.Value != "" {
+			var p Lease
+			if err := json.Unmarshal([]byte(resp.PrevNode.Value), &p); err == nil {
+				prev = &p
+			}
+		}
+
+		var evt LeaseEvent
+		switch resp.Action {
+		case "set", "update", "compareAndSwap", "create":
+			if prev == nil {
+				evt = LeaseEvent{Type: LeaseAssigned, Lease: lease}
+			} else {
+				evt = LeaseEvent{Type: LeaseRenewed, Lease: lease, Prev: prev}
+			}
+		case "delete", "compareAndDelete", "expire":
+			if prev == nil {
+				continue
+			}
+			if resp.Action == "expire" || prev.ExpireTime.Before(time.Now()) {
+				evt = LeaseEvent{Type: LeaseExpired, Lease: *prev}
+			} else {
+				evt = LeaseEvent{Type: LeaseReleased, Lease: *prev}
+			}
+		default:
+			continue
+		}
+
+		select {
+		case out <- evt:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// reapExpired deletes expired leases from the store on interval, which
+// -- for the etcd backend -- by itself surfaces as an Expired event to
+// anyone watching /leases.
+func (p *LeasePool) reapExpired(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		leases, err := p.Leases()
+		if err != nil {
+			continue
+		}
+		now := time.Now()
+		for _, lease := range leases {
+			// foreignHostNic markers reap the same as any other expired
+			// lease: that's what lets Assign probe the address again
+			// once markForeignHost's cool-down passes.
+			if lease.ExpireTime.After(now) {
+				continue
+			}
+			p.store.Delete(lease.IP)
+		}
+	}
+}
+
+// ServeSSE writes pool's lease-change stream to w as Server-Sent-Events
+// until the request's context is cancelled, so monitoring/IPAM-sync
+// tooling can follow lease churn without polling Leases(). It's meant
+// to be mounted under the HTTP API once a web subsystem exists in this
+// tree; for now it's usable standalone via http.HandlerFunc.
+func (p *LeasePool) ServeSSE(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+
+	events := p.Watch(r.Context(), 0)
+	for evt := range events {
+		data, err := json.Marshal(evt)
+		if err != nil {
+			continue
+		}
+		fmt.Fprintf(w, "event: %s\ndata: %s\n\n", evt.Type, data)
+		flusher.Flush()
+	}
+}
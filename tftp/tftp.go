@@ -12,8 +12,10 @@ import (
 const numRetries = 5
 
 type rrq struct {
-	Filename  string
-	BlockSize int
+	Filename   string
+	BlockSize  int
+	WindowSize int
+	TSize      bool
 }
 
 var Log = func(string, ...interface{}) {}
@@ -56,11 +58,31 @@ func transfer(addr net.Addr, req *rrq, pxelinux []byte) {
 
 	bsize := 512
 	if req.BlockSize > 0 {
-		// OACK the blocksize option, ignore all others. Blocksize is
-		// implemented purely because it cuts the roundtrip count 3x.
 		bsize = req.BlockSize
+	}
+	wsize := 1
+	if req.WindowSize > 0 {
+		// windowsize (RFC 7440) lets us stream up to wsize DATA
+		// packets before waiting for an ACK, instead of one
+		// roundtrip per block. Big win on high-latency/lossy links.
+		wsize = req.WindowSize
+	}
+
+	if req.BlockSize > 0 || req.WindowSize > 0 || req.TSize {
+		// OACK whichever of blksize/windowsize/tsize the client
+		// asked for, ignore all others.
 		pkt := []byte{0, 6}
-		pkt = append(pkt, fmt.Sprintf("blksize\x00%d\x00", req.BlockSize)...)
+		if req.BlockSize > 0 {
+			pkt = append(pkt, fmt.Sprintf("blksize\x00%d\x00", req.BlockSize)...)
+		}
+		if req.WindowSize > 0 {
+			pkt = append(pkt, fmt.Sprintf("windowsize\x00%d\x00", req.WindowSize)...)
+		}
+		if req.TSize {
+			// tsize lets PXE ROMs size a buffer up front instead
+			// of aborting when they don't know the length.
+			pkt = append(pkt, fmt.Sprintf("tsize\x00%d\x00", len(pxelinux))...)
+		}
 		if err := sendPacket(conn, pkt, 0); err != nil {
 			// Some PXE ROMs seem to request a transfer with the tsize
 			// option to try and size a buffer, and immediately abort
@@ -72,26 +94,117 @@ func transfer(addr net.Addr, req *rrq, pxelinux []byte) {
 		}
 	}
 
-	toTX := pxelinux
-	seq := uint16(1)
-	buf := make([]byte, bsize+4)
-	buf[1] = 3
-	for len(toTX) > 0 {
-		binary.BigEndian.PutUint16(buf[2:4], seq)
-		l := len(toTX)
+	if err := sendBlocks(conn, splitBlocks(pxelinux, bsize), wsize); err != nil {
+		Log("TFTP", "Transfer to %s failed: %s", addr, err)
+		return
+	}
+
+	Log("TFTP", "Sent pxelinux to %s", addr)
+}
+
+// splitBlocks chops data into bsize-sized DATA payloads, in order.
+func splitBlocks(data []byte, bsize int) [][]byte {
+	blocks := [][]byte{}
+	for {
+		l := len(data)
 		if l > bsize {
 			l = bsize
 		}
-		copy(buf[4:], toTX[:l])
-		if err = sendPacket(conn, buf[:l+4], seq); err != nil {
-			Log("TFTP", "Transfer to %s failed: %s", addr, err)
-			return
+		blocks = append(blocks, data[:l])
+		data = data[l:]
+		if len(data) == 0 {
+			return blocks
 		}
-		seq++
-		toTX = toTX[l:]
 	}
+}
 
-	Log("TFTP", "Sent pxelinux to %s", addr)
+// sendBlocks transmits blocks to conn using Go-Back-N windowing: it
+// sends up to wsize DATA packets per round, then waits for a single
+// cumulative ACK rather than one ACK per block. An ACK for a block
+// short of the window's last one means something in between was
+// dropped, so the window is rewound to resend starting just past the
+// acked block.
+func sendBlocks(conn net.Conn, blocks [][]byte, wsize int) error {
+	total := uint16(len(blocks))
+	hdr := make([]byte, 4)
+	hdr[1] = 3
+
+	pos := uint16(1)
+	for pos <= total {
+		lastSent := pos + uint16(wsize) - 1
+		if lastSent > total {
+			lastSent = total
+		}
+
+		acked, ok := uint16(0), false
+	Window:
+		for try := 0; try < numRetries; try++ {
+			for seq := pos; seq <= lastSent; seq++ {
+				binary.BigEndian.PutUint16(hdr[2:4], seq)
+				if _, err := conn.Write(append(hdr, blocks[seq-1]...)); err != nil {
+					return err
+				}
+			}
+			conn.SetReadDeadline(time.Now().Add(time.Second))
+
+			for {
+				seq, err := readAck(conn)
+				if err != nil {
+					if t, isTimeout := err.(net.Error); isTimeout && t.Timeout() {
+						continue Window
+					}
+					return err
+				}
+				if seq < pos-1 {
+					// Stale/duplicate ACK for a block we've already
+					// moved past (standard TFTP lock-step recovery
+					// behavior on a lossy link): keep listening within
+					// the same deadline instead of resetting the retry
+					// counter and resending the whole window.
+					continue
+				}
+				acked, ok = seq, true
+				break Window
+			}
+		}
+		if !ok {
+			return fmt.Errorf("timed out waiting for ACK for blocks %d-%d", pos, lastSent)
+		}
+
+		if acked >= lastSent {
+			pos = lastSent + 1
+		} else if acked >= pos-1 {
+			// Client only got as far as block `acked`; rewind and
+			// resend the rest of the window from there.
+			pos = acked + 1
+		}
+		// ACKs below pos-1 never get here: the read loop above
+		// skips them and keeps waiting.
+	}
+
+	return nil
+}
+
+// readAck reads a single TFTP packet and returns the acked block
+// number, or an error if it's an ERROR packet or a read failure.
+func readAck(conn net.Conn) (uint16, error) {
+	var recv [256]byte
+	for {
+		n, err := conn.Read(recv[:])
+		if err != nil {
+			return 0, err
+		}
+		if n < 4 {
+			continue
+		}
+		switch binary.BigEndian.Uint16(recv[:2]) {
+		case 4:
+			return binary.BigEndian.Uint16(recv[2:4]), nil
+		case 5:
+			msg, _, _ := nullStr(recv[4:])
+			return 0, fmt.Errorf("client aborted transfer (%q)", msg)
+		}
+	}
 }
 
 // sendPacket sends one TFTP packet to the client and waits for an ack.
@@ -193,6 +306,20 @@ func parseRRQ(addr net.Addr, b []byte) (req *rrq, err error) {
 			if req.BlockSize > 1450 {
 				req.BlockSize = 1450
 			}
+		case "windowsize":
+			if val < 1 || val > 65535 {
+				return nil, fmt.Errorf("%s requested unsupported windowsize %q", addr, val)
+			}
+			req.WindowSize = val
+			// Clamp to something sane; huge windows just mean huge
+			// retransmits on loss, which defeats the point.
+			if req.WindowSize > 16 {
+				req.WindowSize = 16
+			}
+		case "tsize":
+			// Client is asking us to report the transfer size via
+			// OACK; the value it sent (usually 0) is irrelevant.
+			req.TSize = true
 		}
 	}
 
@@ -206,4 +333,4 @@ func nullStr(b []byte) (str string, remaining []byte, ok bool) {
 		return "", nil, false
 	}
 	return string(b[:off]), b[off+1:], true
-}
\ No newline at end of file
+}
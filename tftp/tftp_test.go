@@ -0,0 +1,88 @@
+package tftp
+
+import (
+	"encoding/binary"
+	"net"
+	"testing"
+)
+
+func TestParseRRQOptions(t *testing.T) {
+	addr := &net.UDPAddr{}
+	b := []byte{0, 1}
+	b = append(b, "pxelinux.0"...)
+	b = append(b, 0)
+	b = append(b, "octet"...)
+	b = append(b, 0)
+	b = append(b, "blksize\x001024\x00"...)
+	b = append(b, "windowsize\x0032\x00"...)
+	b = append(b, "tsize\x000\x00"...)
+
+	req, err := parseRRQ(addr, b)
+	if err != nil {
+		t.Fatalf("parseRRQ: %v", err)
+	}
+	if req.Filename != "pxelinux.0" {
+		t.Errorf("Filename = %q, want %q", req.Filename, "pxelinux.0")
+	}
+	if req.BlockSize != 1024 {
+		t.Errorf("BlockSize = %d, want 1024", req.BlockSize)
+	}
+	if req.WindowSize != 16 {
+		t.Errorf("WindowSize = %d, want 16 (clamped)", req.WindowSize)
+	}
+	if !req.TSize {
+		t.Error("TSize = false, want true")
+	}
+}
+
+func TestSplitBlocks(t *testing.T) {
+	data := make([]byte, 1025)
+	blocks := splitBlocks(data, 512)
+	if len(blocks) != 3 {
+		t.Fatalf("len(blocks) = %d, want 3", len(blocks))
+	}
+	if len(blocks[0]) != 512 || len(blocks[1]) != 512 || len(blocks[2]) != 1 {
+		t.Fatalf("unexpected block sizes: %d, %d, %d", len(blocks[0]), len(blocks[1]), len(blocks[2]))
+	}
+}
+
+// TestSendBlocksWindowed exercises the Go-Back-N rewind path: the whole
+// window is sent, the client only acks partway through it, and the
+// remainder of the window must be resent before the transfer completes.
+func TestSendBlocksWindowed(t *testing.T) {
+	server, client := net.Pipe()
+	defer server.Close()
+	defer client.Close()
+
+	blocks := [][]byte{{1}, {2}, {3}, {4}}
+	done := make(chan error, 1)
+	go func() { done <- sendBlocks(server, blocks, 4) }()
+
+	ackFor := func(seq uint16) {
+		ack := make([]byte, 4)
+		ack[1] = 4
+		binary.BigEndian.PutUint16(ack[2:4], seq)
+		if _, err := client.Write(ack); err != nil {
+			t.Fatalf("write ack: %v", err)
+		}
+	}
+
+	recv := make([]byte, 516)
+	for i := 0; i < 4; i++ {
+		if _, err := client.Read(recv); err != nil {
+			t.Fatalf("read data: %v", err)
+		}
+	}
+	ackFor(2)
+
+	for i := 0; i < 2; i++ {
+		if _, err := client.Read(recv); err != nil {
+			t.Fatalf("read resent data: %v", err)
+		}
+	}
+	ackFor(4)
+
+	if err := <-done; err != nil {
+		t.Fatalf("sendBlocks: %v", err)
+	}
+}